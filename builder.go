@@ -1,40 +1,71 @@
 package commandbuilder
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+
+	"github.com/vts0/commandbuilder/quote"
 )
 
 // Command represents a command with its arguments, environment variables, redirections, and chaining logic.
 type Command struct {
-	name         string
-	subcommands  []string
-	args         []argument
-	useSudo      bool
-	next         *Command
-	operator     string
-	redirections []string
-	background   bool
-	env          map[string]string
-	stderrRedir  string
-	mergeStdErr  bool
-	stdin        io.Reader
-	group        bool
-	tempFiles    []tempFile
+	name            string
+	subcommands     []string
+	args            []argument
+	useSudo         bool
+	next            *Command
+	operator        string
+	redirections    []string
+	background      bool
+	env             map[string]string
+	stderrRedir     string
+	mergeStdErr     bool
+	stdin           io.Reader
+	group           bool
+	tempFiles       []tempFile
+	heredoc         *heredoc
+	herestring      string
+	fdRedirs        []string
+	secretEnvKeys   map[string]bool
+	tempFileEnvKeys map[string]int
 }
 
 // argument represents a command argument with additional metadata.
 type argument struct {
-	value    string
-	quoted   bool
-	expand   bool
-	isPath   bool
-	isGlob   bool
-	keyValue bool
-	key      string
-}
+	value          string
+	quoted         bool
+	expand         bool
+	isPath         bool
+	isGlob         bool
+	keyValue       bool
+	key            string
+	procSub        *CommandBuilder
+	procSubIn      bool
+	secret         bool
+	secretProvider SecretProvider
+	tempFileRef    int
+	hasTempFileRef bool
+}
+
+// heredoc represents a "<<TAG" (or indented "<<-TAG") redirection.
+type heredoc struct {
+	tag      string
+	body     string
+	indented bool
+}
+
+// ProcSubDir selects the direction of a process substitution argument.
+type ProcSubDir int
+
+const (
+	// ProcSubRead renders "<(cmd)", substituting the command's stdout.
+	ProcSubRead ProcSubDir = iota
+	// ProcSubWrite renders ">(cmd)", substituting the command's stdin.
+	ProcSubWrite
+)
 
 // tempFile represents a temporary file to be created for the command.
 type tempFile struct {
@@ -44,7 +75,24 @@ type tempFile struct {
 
 // CommandBuilder provides a fluent interface for building commands.
 type CommandBuilder struct {
-	command *Command
+	command     *Command
+	head        *Command
+	quotePolicy quote.Policy
+	logger      func(event string)
+}
+
+// chainHead returns the Command this builder's chain actually starts at.
+// PipeTo/And/Or return a *CommandBuilder for the newly-chained node, not the
+// head, so Build/Grouped/Background/ToExecCmd need this to operate on the
+// whole chain no matter which stage's builder they're called on. head is
+// unset for a builder that was never the result of chain() - which is also
+// the case for any builder constructed to wrap a single Command directly
+// (e.g. Command.String) - so it falls back to command itself.
+func (b *CommandBuilder) chainHead() *Command {
+	if b.head != nil {
+		return b.head
+	}
+	return b.command
 }
 
 // New creates a new CommandBuilder with the specified command name.
@@ -116,6 +164,14 @@ func (b *CommandBuilder) WithVariable(name string) *CommandBuilder {
 	return b
 }
 
+// WithQuotePolicy sets the quoting policy Build uses for this command,
+// letting callers target a shell other than POSIX (e.g. quote.Windows).
+// The default, if never called, is quote.POSIX.
+func (b *CommandBuilder) WithQuotePolicy(policy quote.Policy) *CommandBuilder {
+	b.quotePolicy = policy
+	return b
+}
+
 // WithSudo enables sudo for the command.
 func (b *CommandBuilder) WithSudo() *CommandBuilder {
 	b.command.useSudo = true
@@ -164,13 +220,75 @@ func (b *CommandBuilder) MergeStdoutAndStderr() *CommandBuilder {
 	return b
 }
 
+// WithHeredoc attaches a "<<TAG" here-document, rendering "<<TAG\n...body...\nTAG".
+// It panics if tag appears in body as its own exact line, since that would
+// truncate the document early.
+func (b *CommandBuilder) WithHeredoc(tag, body string) *CommandBuilder {
+	validateHeredocTag(tag, body, false)
+	b.command.heredoc = &heredoc{tag: tag, body: body}
+	return b
+}
+
+// WithHeredocIndented attaches a "<<-TAG" here-document whose body lines keep
+// their leading tabs in the source but have them stripped on render.
+func (b *CommandBuilder) WithHeredocIndented(tag, body string) *CommandBuilder {
+	validateHeredocTag(tag, body, true)
+	b.command.heredoc = &heredoc{tag: tag, body: body, indented: true}
+	return b
+}
+
+// validateHeredocTag panics if body contains a line that would terminate the
+// heredoc early. "<<-TAG" strips each line's leading tabs before the shell
+// compares it to TAG, so a tab-indented tag line still terminates it; plain
+// "<<TAG" requires an exact match with no leading whitespace at all.
+func validateHeredocTag(tag, body string, indented bool) {
+	for _, line := range strings.Split(body, "\n") {
+		if indented {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == tag {
+			panic(fmt.Sprintf("commandbuilder: heredoc tag %q appears in body", tag))
+		}
+	}
+}
+
+// WithHerestring attaches a "<<< 'value'" here-string.
+func (b *CommandBuilder) WithHerestring(value string) *CommandBuilder {
+	b.command.herestring = value
+	return b
+}
+
+// RedirectFD duplicates file descriptor "to" onto "from" (e.g. RedirectFD(2, 1)
+// renders "2>&1"). Use a sequence of calls to express descriptor swaps like
+// "3>&1 1>&2 2>&3".
+func (b *CommandBuilder) RedirectFD(from, to int) *CommandBuilder {
+	b.command.fdRedirs = append(b.command.fdRedirs, fmt.Sprintf("%d>&%d", from, to))
+	return b
+}
+
+// RedirectFDToFile redirects file descriptor fd to path (e.g. "3> path").
+func (b *CommandBuilder) RedirectFDToFile(fd int, path string) *CommandBuilder {
+	b.command.fdRedirs = append(b.command.fdRedirs, fmt.Sprintf("%d> %s", fd, path))
+	return b
+}
+
+// WithProcessSubstitution adds a process-substitution argument, rendering
+// "<(cmd)" (ProcSubRead) or ">(cmd)" (ProcSubWrite).
+func (b *CommandBuilder) WithProcessSubstitution(inner *CommandBuilder, dir ProcSubDir) *CommandBuilder {
+	b.command.args = append(b.command.args, argument{procSub: inner, procSubIn: dir == ProcSubRead})
+	return b
+}
+
 // WithStdin sets the command's standard input.
 func (b *CommandBuilder) WithStdin(reader io.Reader) *CommandBuilder {
 	b.command.stdin = reader
 	return b
 }
 
-// WithTempFile creates a temporary file for the command.
+// WithTempFile creates a temporary file for the command. The file is
+// materialized when the chain is executed (ToExecCmd), so its path isn't
+// known to the caller at build time; use WithTempFileArg or WithTempFileEnv
+// instead if the command needs to reference the generated path.
 func (b *CommandBuilder) WithTempFile(content string, mode os.FileMode) *CommandBuilder {
 	b.command.tempFiles = append(b.command.tempFiles, tempFile{
 		content: content,
@@ -179,79 +297,163 @@ func (b *CommandBuilder) WithTempFile(content string, mode os.FileMode) *Command
 	return b
 }
 
-// Grouped groups the command and its chained commands in a subshell.
+// WithTempFileArg creates a temporary file, like WithTempFile, and appends
+// an argument that resolves to that file's materialized path once the
+// shell-free exec path (ToExecCmd) writes it to disk - letting the command
+// reference its own generated input without the caller inventing a path up
+// front. Build() has no way to materialize a temp file as part of a plain
+// shell string, so it renders the argument as a "<tempfile>" placeholder;
+// use ToExecCmd() to get a command that actually resolves to the real path.
+func (b *CommandBuilder) WithTempFileArg(content string, mode os.FileMode) *CommandBuilder {
+	idx := len(b.command.tempFiles)
+	b.command.tempFiles = append(b.command.tempFiles, tempFile{
+		content: content,
+		mode:    mode,
+	})
+	b.command.args = append(b.command.args, argument{tempFileRef: idx, hasTempFileRef: true})
+	return b
+}
+
+// WithTempFileEnv creates a temporary file, like WithTempFile, and binds its
+// materialized path to the given environment variable once the shell-free
+// exec path (ToExecCmd) writes it to disk. Like WithTempFileArg, Build()
+// renders this as a "<tempfile>" placeholder rather than a real path.
+func (b *CommandBuilder) WithTempFileEnv(key, content string, mode os.FileMode) *CommandBuilder {
+	idx := len(b.command.tempFiles)
+	b.command.tempFiles = append(b.command.tempFiles, tempFile{
+		content: content,
+		mode:    mode,
+	})
+	if b.command.tempFileEnvKeys == nil {
+		b.command.tempFileEnvKeys = make(map[string]int)
+	}
+	b.command.tempFileEnvKeys[key] = idx
+	return b
+}
+
+// Grouped wraps the whole chain - this command plus anything piped/chained
+// onto it with PipeTo/And/Or - in "( ... )" subshell grouping. It can be
+// called on any stage's builder, including the one PipeTo/And/Or returns;
+// Build renders the parens around the entire chain's rendered sequence
+// regardless, not just the called-on command's own segment.
 func (b *CommandBuilder) Grouped() *CommandBuilder {
-	b.command.group = true
+	b.chainHead().group = true
 	return b
 }
 
-// Background runs the command in the background.
+// Background runs the whole chain in the background, appending "&" once
+// after everything piped/chained onto this command. Can be called on any
+// stage's builder, same as Grouped.
 func (b *CommandBuilder) Background() *CommandBuilder {
-	b.command.background = true
+	b.chainHead().background = true
 	return b
 }
 
 // PipeTo chains the current command to the next command using a pipe ("|").
+// It returns next, so additional stages can be chained fluently:
+// head.PipeTo(middle).PipeTo(tail).
 func (b *CommandBuilder) PipeTo(next *CommandBuilder) *CommandBuilder {
 	return b.chain(next, "|")
 }
 
-// And chains the current command to the next command using a logical AND ("&&").
+// And chains the current command to the next command using a logical AND
+// ("&&"). It returns next, so additional stages can be chained fluently.
 func (b *CommandBuilder) And(next *CommandBuilder) *CommandBuilder {
 	return b.chain(next, "&&")
 }
 
-// Or chains the current command to the next command using a logical OR ("||").
+// Or chains the current command to the next command using a logical OR
+// ("||"). It returns next, so additional stages can be chained fluently.
 func (b *CommandBuilder) Or(next *CommandBuilder) *CommandBuilder {
 	return b.chain(next, "||")
 }
 
-// chain is a helper method for chaining commands with an operator.
+// chain is a helper method for chaining commands with an operator. It
+// returns next, not b, so that PipeTo/And/Or can be chained fluently across
+// 3+ stages (b.PipeTo(c).PipeTo(d) must advance to c before linking d). next
+// inherits b's chain head so that later stages' builders still resolve back
+// to the same head regardless of how deep the fluent chain goes.
 func (b *CommandBuilder) chain(next *CommandBuilder, op string) *CommandBuilder {
 	b.command.operator = op
 	b.command.next = next.command
-	return b
+	next.head = b.chainHead()
+	return next
 }
 
-// Build constructs the final command string.
+// Build constructs the final command string. Any lazy secret arguments
+// (WithSecretArgumentFrom) are resolved first; a provider that fails to
+// resolve leaves that argument's value empty rather than failing Build -
+// use ToExecCmd, which surfaces the same fetch error, when that distinction
+// matters.
 func (b *CommandBuilder) Build() string {
+	_ = b.resolveSecretProviders(context.Background())
+	return b.buildWithRedaction(false)
+}
+
+func (b *CommandBuilder) buildWithRedaction(redact bool) string {
+	policy := b.quotePolicy
+	if policy == nil {
+		policy = quote.POSIX
+	}
+
 	var parts []string
-	cmd := b.command
+	cmd := b.chainHead()
 
 	for cmd != nil {
 		var segmentParts []string
 
-		// Environment variables
-		if len(cmd.env) > 0 {
-			envParts := make([]string, 0, len(cmd.env))
+		// Environment variables. WithTempFileEnv bindings have no materialized
+		// path yet at render time - Build() never writes temp files to disk -
+		// so they render as a "<tempfile>" placeholder instead of silently
+		// vanishing from the rendered string.
+		if len(cmd.env) > 0 || len(cmd.tempFileEnvKeys) > 0 {
+			envParts := make([]string, 0, len(cmd.env)+len(cmd.tempFileEnvKeys))
 			for k, v := range cmd.env {
-				envParts = append(envParts, fmt.Sprintf("%s=%s", k, shellEscape(v)))
+				if redact && cmd.secretEnvKeys[k] {
+					v = redacted
+				}
+				envParts = append(envParts, fmt.Sprintf("%s=%s", k, policy.Quote(v)))
+			}
+			for k := range cmd.tempFileEnvKeys {
+				envParts = append(envParts, k+"=<tempfile>")
 			}
 			segmentParts = append(segmentParts, strings.Join(envParts, " "))
 		}
 
 		// Command name and subcommands
-		cmdParts := []string{shellEscape(cmd.name)}
+		cmdParts := []string{policy.Quote(cmd.name)}
 		for _, sub := range cmd.subcommands {
-			cmdParts = append(cmdParts, shellEscape(sub))
+			cmdParts = append(cmdParts, policy.Quote(sub))
 		}
 
 		// Arguments processing
 		for _, arg := range cmd.args {
+			value := arg.value
+			if redact && arg.secret {
+				value = redacted
+			}
+
 			var part string
 			switch {
+			case arg.hasTempFileRef:
+				// No materialized path exists yet at render time - Build()
+				// never writes temp files to disk - so render a placeholder
+				// instead of silently emitting an empty argument.
+				part = "<tempfile>"
+			case arg.procSub != nil:
+				part = renderProcessSubstitution(arg, redact)
 			case arg.keyValue:
-				part = fmt.Sprintf("%s=%s", arg.key, processArgument(arg.value, arg))
+				part = fmt.Sprintf("%s=%s", arg.key, processArgument(value, arg, policy))
 			case arg.quoted:
-				part = fmt.Sprintf(`"%s"`, processArgument(arg.value, arg))
+				part = policy.QuoteExpand(value)
 			case arg.expand:
-				part = fmt.Sprintf("$%s", arg.value)
+				part = fmt.Sprintf("$%s", value)
 			case arg.isGlob:
-				part = arg.value // Globs should not be escaped
+				part = value // Globs should not be escaped
 			case arg.isPath:
-				part = shellEscapePath(arg.value)
+				part = shellEscapePath(value)
 			default:
-				part = processArgument(arg.value, arg)
+				part = processArgument(value, arg, policy)
 			}
 			cmdParts = append(cmdParts, part)
 		}
@@ -261,12 +463,7 @@ func (b *CommandBuilder) Build() string {
 			cmdParts = append([]string{"sudo"}, cmdParts...)
 		}
 
-		// Grouping
-		if cmd.group {
-			segmentParts = append(segmentParts, "("+strings.Join(cmdParts, " ")+")")
-		} else {
-			segmentParts = append(segmentParts, strings.Join(cmdParts, " "))
-		}
+		segmentParts = append(segmentParts, strings.Join(cmdParts, " "))
 
 		// Redirections
 		if len(cmd.redirections) > 0 {
@@ -283,9 +480,17 @@ func (b *CommandBuilder) Build() string {
 			segmentParts = append(segmentParts, "2>&1")
 		}
 
-		// Background execution
-		if cmd.background {
-			segmentParts = append(segmentParts, "&")
+		// Numbered file descriptor redirections (e.g. fd swaps)
+		if len(cmd.fdRedirs) > 0 {
+			segmentParts = append(segmentParts, strings.Join(cmd.fdRedirs, " "))
+		}
+
+		// Heredoc / herestring
+		if cmd.heredoc != nil {
+			segmentParts = append(segmentParts, renderHeredoc(cmd.heredoc))
+		}
+		if cmd.herestring != "" {
+			segmentParts = append(segmentParts, fmt.Sprintf("<<< %s", policy.Quote(cmd.herestring)))
 		}
 
 		// Join segment parts
@@ -299,22 +504,48 @@ func (b *CommandBuilder) Build() string {
 		cmd = cmd.next
 	}
 
-	return strings.Join(parts, " ")
+	result := strings.Join(parts, " ")
+
+	// Grouping and backgrounding apply to the whole chain - a pipe/&&/||
+	// sequence headed by a Grouped() or Background() builder - not just the
+	// head node's own segment, so they're applied once here rather than
+	// per-node above. chainHead(), not b.command, carries them: Grouped/
+	// Background may have been called on any stage's builder.
+	if b.chainHead().group {
+		result = "(" + result + ")"
+	}
+	if b.chainHead().background {
+		result += " &"
+	}
+	return result
 }
 
-// Helper functions
-func processArgument(value string, arg argument) string {
-	if arg.isPath {
-		return shellEscapePath(value)
+// renderHeredoc renders a heredoc as "<<TAG\n...body...\nTAG" ("<<-TAG" for
+// the indented form).
+func renderHeredoc(h *heredoc) string {
+	marker := "<<"
+	if h.indented {
+		marker = "<<-"
+	}
+	return fmt.Sprintf("%s%s\n%s\n%s", marker, h.tag, h.body, h.tag)
+}
+
+// renderProcessSubstitution renders "<(cmd)" or ">(cmd)" for a process
+// substitution argument.
+func renderProcessSubstitution(arg argument, redact bool) string {
+	inner := arg.procSub.buildWithRedaction(redact)
+	if arg.procSubIn {
+		return fmt.Sprintf("<(%s)", inner)
 	}
-	return shellEscape(value)
+	return fmt.Sprintf(">(%s)", inner)
 }
 
-func shellEscape(s string) string {
-	if strings.ContainsAny(s, " \t\n\"'$&;|<>`") {
-		return fmt.Sprintf("'%s'", strings.ReplaceAll(s, "'", "'\"'\"'"))
+// Helper functions
+func processArgument(value string, arg argument, policy quote.Policy) string {
+	if arg.isPath {
+		return shellEscapePath(value)
 	}
-	return s
+	return policy.Quote(value)
 }
 
 func shellEscapePath(path string) string {
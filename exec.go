@@ -0,0 +1,538 @@
+package commandbuilder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Runner executes an ExecCmd chain. Tests can inject a fake Runner instead of
+// letting commands hit the real OS.
+type Runner interface {
+	Run(cmd *ExecCmd) error
+	Output(cmd *ExecCmd) ([]byte, error)
+	Start(cmd *ExecCmd) error
+}
+
+// osRunner is the default Runner, backed by os/exec.
+type osRunner struct{}
+
+func (osRunner) Run(cmd *ExecCmd) error              { return cmd.run() }
+func (osRunner) Output(cmd *ExecCmd) ([]byte, error) { return cmd.output() }
+func (osRunner) Start(cmd *ExecCmd) error            { return cmd.start() }
+
+// ExecCmd is a shell-free, materialized view of a CommandBuilder chain. Unlike
+// Build(), it never passes through "sh -c": pipes and logical operators are
+// wired and evaluated directly in Go, so behavior is identical on Windows and
+// Unix regardless of whether a shell is present.
+type ExecCmd struct {
+	cmd           *exec.Cmd
+	op            string
+	next          *ExecCmd
+	runner        Runner
+	tempFilePaths []string
+	openFiles     []*os.File
+	logger        func(event string)
+	logLine       string
+}
+
+// ToExecCmd materializes the builder's command chain as an *ExecCmd tree. Any
+// WithLogger hook is carried over and fired, with secrets redacted, right
+// before the chain runs. Lazy secret arguments (WithSecretArgumentFrom) are
+// resolved up front; a provider fetch error is returned rather than running
+// with a missing secret.
+func (b *CommandBuilder) ToExecCmd() (*ExecCmd, error) {
+	if err := b.resolveSecretProviders(context.Background()); err != nil {
+		return nil, err
+	}
+
+	e, err := newExecCmd(b.chainHead())
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		e.logger = b.logger
+		e.logLine = b.BuildRedacted()
+	}
+	return e, nil
+}
+
+func newExecCmd(cmd *Command) (*ExecCmd, error) {
+	if cmd == nil {
+		return nil, nil
+	}
+
+	tempFilePaths := make([]string, len(cmd.tempFiles))
+	for i, tf := range cmd.tempFiles {
+		path, err := writeTempFile(tf)
+		if err != nil {
+			removeTempFiles(tempFilePaths[:i])
+			return nil, err
+		}
+		tempFilePaths[i] = path
+	}
+
+	argv, err := execArgv(cmd, tempFilePaths)
+	if err != nil {
+		return nil, err
+	}
+	c := exec.Command(argv[0], argv[1:]...)
+	envUpdates := cmd.env
+	if len(cmd.tempFileEnvKeys) > 0 {
+		envUpdates = make(map[string]string, len(cmd.env)+len(cmd.tempFileEnvKeys))
+		for k, v := range cmd.env {
+			envUpdates[k] = v
+		}
+		for key, idx := range cmd.tempFileEnvKeys {
+			envUpdates[key] = tempFilePaths[idx]
+		}
+	}
+	if len(envUpdates) > 0 {
+		c.Env = mergeEnvValues(os.Environ(), envUpdates)
+	}
+	if cmd.stdin != nil {
+		c.Stdin = cmd.stdin
+	}
+
+	e := &ExecCmd{cmd: c, op: cmd.operator, runner: osRunner{}, tempFilePaths: tempFilePaths}
+
+	if err := wireIO(e, cmd); err != nil {
+		return nil, err
+	}
+
+	next, err := newExecCmd(cmd.next)
+	if err != nil {
+		return nil, err
+	}
+	e.next = next
+	return e, nil
+}
+
+// wireIO applies cmd's file redirections, heredoc, and here-string onto e's
+// underlying *exec.Cmd, in the same precedence order Build renders them:
+// redirections, then the explicit stderr redirection, then the merged-stderr
+// flag, then heredoc/here-string stdin. Any file opened along the way is
+// tracked on e so cleanup can close it once the process finishes.
+//
+// RedirectFD/RedirectFDToFile aren't wired here: duplicating an arbitrary
+// file descriptor the way a real shell does isn't something *exec.Cmd's
+// Stdin/Stdout/Stderr fields can express, so a Command carrying fdRedirs
+// fails closed with an error instead of silently dropping them.
+func wireIO(e *ExecCmd, cmd *Command) error {
+	for _, r := range cmd.redirections {
+		if err := applyFileRedirection(e, r); err != nil {
+			return err
+		}
+	}
+
+	if cmd.stderrRedir != "" {
+		if err := applyFileRedirection(e, cmd.stderrRedir); err != nil {
+			return err
+		}
+	}
+
+	if cmd.mergeStdErr {
+		e.cmd.Stderr = e.cmd.Stdout
+	}
+
+	if len(cmd.fdRedirs) > 0 {
+		return fmt.Errorf("commandbuilder: %v: RedirectFD/RedirectFDToFile aren't supported by the shell-free exec path; use Build() with a shell instead", cmd.fdRedirs)
+	}
+
+	if cmd.heredoc != nil {
+		e.cmd.Stdin = strings.NewReader(heredocStdin(cmd.heredoc))
+	}
+	if cmd.herestring != "" {
+		e.cmd.Stdin = strings.NewReader(cmd.herestring + "\n")
+	}
+
+	return nil
+}
+
+// applyFileRedirection opens the file named by a single redirection or
+// stderr-redirection string (e.g. "> out.txt", ">> out.txt", "< in.txt",
+// "2> err.txt") and wires it onto the matching stream.
+func applyFileRedirection(e *ExecCmd, spec string) error {
+	op, path, ok := splitRedirectionSpec(spec)
+	if !ok {
+		return fmt.Errorf("commandbuilder: unrecognized redirection %q", spec)
+	}
+
+	var f *os.File
+	var err error
+	switch op {
+	case ">", "2>":
+		f, err = os.Create(path)
+	case ">>":
+		f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	case "<":
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.openFiles = append(e.openFiles, f)
+	switch op {
+	case ">", ">>":
+		e.cmd.Stdout = f
+	case "2>":
+		e.cmd.Stderr = f
+	case "<":
+		e.cmd.Stdin = f
+	}
+	return nil
+}
+
+// splitRedirectionSpec splits a "> path" / "2> path" style string into its
+// operator and path, tolerating the single space Build's renderer always
+// inserts between them.
+func splitRedirectionSpec(spec string) (op, path string, ok bool) {
+	for _, candidate := range []string{"2>", ">>", ">", "<"} {
+		prefix := candidate + " "
+		if strings.HasPrefix(spec, prefix) {
+			return candidate, spec[len(prefix):], true
+		}
+	}
+	return "", "", false
+}
+
+// heredocStdin renders a heredoc's body as the bytes a program reading
+// stdin would actually see: the indented ("<<-TAG") form has its leading
+// tabs stripped per line, the same as a real shell does before handing the
+// document to the command, and a trailing newline is added before the
+// (not transmitted) TAG line.
+func heredocStdin(h *heredoc) string {
+	body := h.body
+	if h.indented {
+		lines := strings.Split(body, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimLeft(line, "\t")
+		}
+		body = strings.Join(lines, "\n")
+	}
+	return body + "\n"
+}
+
+// writeTempFile materializes a tempFile as a real file on disk, to be cleaned
+// up once the command it belongs to finishes.
+func writeTempFile(tf tempFile) (string, error) {
+	f, err := os.CreateTemp("", "commandbuilder-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(tf.content); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if tf.mode != 0 {
+		if err := os.Chmod(f.Name(), tf.mode); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+	}
+	return f.Name(), nil
+}
+
+// removeTempFiles removes each materialized temp file path, best-effort.
+func removeTempFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// cleanupTempFiles removes the temp files created for this node.
+func (e *ExecCmd) cleanupTempFiles() {
+	removeTempFiles(e.tempFilePaths)
+}
+
+// closeOpenFiles closes the files opened by applyFileRedirection for this
+// node's redirections, once the process they were wired into has finished.
+func (e *ExecCmd) closeOpenFiles() {
+	for _, f := range e.openFiles {
+		f.Close()
+	}
+}
+
+// execArgv renders the argv for a single Command node, without any shell
+// escaping: os/exec passes each element as a distinct argument. A
+// WithProcessSubstitution argument has no plain-string value a bare argv
+// entry can carry - os/exec has no equivalent of a shell's "<(cmd)"/">(cmd)"
+// file-like substitution - so it fails closed with an error rather than
+// emitting "" and silently running the wrong command. tempFilePaths holds
+// the materialized path for each of cmd.tempFiles, in order, so a
+// WithTempFileArg argument can be resolved to the real path on disk.
+func execArgv(cmd *Command, tempFilePaths []string) ([]string, error) {
+	var argv []string
+	if cmd.useSudo {
+		argv = append(argv, "sudo")
+	}
+	argv = append(argv, cmd.name)
+	argv = append(argv, cmd.subcommands...)
+	for _, arg := range cmd.args {
+		if arg.procSub != nil {
+			return nil, fmt.Errorf("commandbuilder: process substitution isn't supported by the shell-free exec path; use Build() with a shell instead")
+		}
+		if arg.hasTempFileRef {
+			argv = append(argv, tempFilePaths[arg.tempFileRef])
+			continue
+		}
+		value := arg.value
+		switch {
+		case arg.keyValue:
+			argv = append(argv, fmt.Sprintf("%s=%s", arg.key, value))
+		case arg.expand:
+			argv = append(argv, os.Getenv(value))
+		default:
+			argv = append(argv, value)
+		}
+	}
+	return argv, nil
+}
+
+// mergeEnvValues applies EnvUpdates ("KEY=VALUE" to set, "KEY=" to unset) on
+// top of a base environment, returning the merged "KEY=VALUE" slice that
+// os/exec.Cmd.Env expects.
+func mergeEnvValues(base []string, updates map[string]string) []string {
+	merged := make(map[string]string, len(base)+len(updates))
+	for _, kv := range base {
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			merged[kv[:i]] = kv[i+1:]
+		}
+	}
+	for k, v := range updates {
+		if v == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+
+	out := make([]string, 0, len(merged))
+	for k, v := range merged {
+		out = append(out, k+"="+v)
+	}
+	return out
+}
+
+// Args returns the argv this command will be run with, sudo prefix included.
+func (e *ExecCmd) Args() []string {
+	return e.cmd.Args
+}
+
+// AddEnvVars appends "KEY=VALUE" entries to the process environment,
+// inheriting the current environment if none has been set yet.
+func (e *ExecCmd) AddEnvVars(vars ...string) *ExecCmd {
+	if e.cmd.Env == nil {
+		e.cmd.Env = os.Environ()
+	}
+	e.cmd.Env = append(e.cmd.Env, vars...)
+	return e
+}
+
+// GetCmd returns the underlying *exec.Cmd for this node.
+func (e *ExecCmd) GetCmd() *exec.Cmd {
+	return e.cmd
+}
+
+// WithStdin sets this node's standard input.
+func (e *ExecCmd) WithStdin(r io.Reader) *ExecCmd {
+	e.cmd.Stdin = r
+	return e
+}
+
+// WithStdout sets this node's standard output.
+func (e *ExecCmd) WithStdout(w io.Writer) *ExecCmd {
+	e.cmd.Stdout = w
+	return e
+}
+
+// WithStderr sets this node's standard error.
+func (e *ExecCmd) WithStderr(w io.Writer) *ExecCmd {
+	e.cmd.Stderr = w
+	return e
+}
+
+// WithRunner overrides the Runner used by Run/Output/Start, e.g. with a fake
+// for tests.
+func (e *ExecCmd) WithRunner(r Runner) *ExecCmd {
+	e.runner = r
+	return e
+}
+
+// Run executes the chain and waits for it to finish.
+func (e *ExecCmd) Run() error {
+	e.logEvent()
+	return e.runner.Run(e)
+}
+
+// Output executes the chain and returns the final command's stdout.
+func (e *ExecCmd) Output() ([]byte, error) {
+	e.logEvent()
+	return e.runner.Output(e)
+}
+
+// logEvent fires the WithLogger hook, if any, with secrets redacted.
+func (e *ExecCmd) logEvent() {
+	if e.logger != nil {
+		e.logger(e.logLine)
+	}
+}
+
+// Background starts the chain without waiting for it, returning a handle
+// whose Wait can be called once the caller is ready to block.
+func (e *ExecCmd) Background() (*BackgroundHandle, error) {
+	e.logEvent()
+	if err := e.runner.Start(e); err != nil {
+		return nil, err
+	}
+	return &BackgroundHandle{cmd: e}, nil
+}
+
+// BackgroundHandle is returned by ExecCmd.Background and lets the caller wait
+// on a command that was started without blocking.
+type BackgroundHandle struct {
+	cmd *ExecCmd
+}
+
+// Wait blocks until the backgrounded chain finishes.
+func (h *BackgroundHandle) Wait() error {
+	return h.cmd.wait()
+}
+
+// run executes the chain and evaluates && / || in Go, so no shell is ever
+// invoked.
+func (e *ExecCmd) run() error {
+	_, err := e.exec(false)
+	return err
+}
+
+func (e *ExecCmd) output() ([]byte, error) {
+	return e.exec(true)
+}
+
+func (e *ExecCmd) start() error {
+	return e.startSegment(pipeSegment(e))
+}
+
+// pipeSegment returns e and every node chained to it with "|", in order.
+// These are the nodes that must be started together, with stdout/stdin
+// wired pairwise, before any of them can be waited on.
+func pipeSegment(e *ExecCmd) []*ExecCmd {
+	segment := []*ExecCmd{e}
+	for segment[len(segment)-1].op == "|" && segment[len(segment)-1].next != nil {
+		segment = append(segment, segment[len(segment)-1].next)
+	}
+	return segment
+}
+
+// startSegment wires a StdoutPipe from each node into the next node's Stdin
+// and starts every process in the segment, in order. StdoutPipe must be
+// obtained before its own node starts and before the downstream node - whose
+// Stdin it feeds - starts, which is why this can't simply loop and Start
+// each node independently.
+func (e *ExecCmd) startSegment(segment []*ExecCmd) error {
+	for i, node := range segment {
+		if i+1 < len(segment) {
+			pipe, err := node.cmd.StdoutPipe()
+			if err != nil {
+				return err
+			}
+			segment[i+1].cmd.Stdin = pipe
+		}
+		if err := node.cmd.Start(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitSegment waits for every process in the segment and returns the last
+// node's error: upstream pipe stages are expected to exit once the
+// downstream reader is done with them, so only the last stage's status
+// feeds into a following && / ||.
+func waitSegment(segment []*ExecCmd) error {
+	var last error
+	for i, node := range segment {
+		err := node.cmd.Wait()
+		if i == len(segment)-1 {
+			last = err
+		}
+	}
+	return last
+}
+
+// wait waits on an already-started chain (via Background/start) and then
+// evaluates any && / || that follows it.
+func (e *ExecCmd) wait() error {
+	segment := pipeSegment(e)
+	defer func() {
+		for _, node := range segment {
+			node.cleanupTempFiles()
+			node.closeOpenFiles()
+		}
+	}()
+
+	err := waitSegment(segment)
+	_, chainErr := continueChain(segment[len(segment)-1], err, nil)
+	return chainErr
+}
+
+// exec starts and waits on the pipe segment beginning at e, optionally
+// capturing the final stage's stdout, then evaluates any && / || that
+// follows the segment.
+func (e *ExecCmd) exec(captureOutput bool) ([]byte, error) {
+	segment := pipeSegment(e)
+	defer func() {
+		for _, node := range segment {
+			node.cleanupTempFiles()
+			node.closeOpenFiles()
+		}
+	}()
+
+	last := segment[len(segment)-1]
+	var buf bytes.Buffer
+	if captureOutput && last.cmd.Stdout == nil {
+		last.cmd.Stdout = &buf
+	}
+
+	if err := e.startSegment(segment); err != nil {
+		return nil, err
+	}
+	err := waitSegment(segment)
+	return continueChain(last, err, &buf)
+}
+
+// continueChain evaluates the operator following a finished pipe segment:
+// && runs the next segment only on success, || only on failure, and a bare
+// chain end just surfaces the segment's own result.
+func continueChain(last *ExecCmd, err error, buf *bytes.Buffer) ([]byte, error) {
+	var out []byte
+	if buf != nil {
+		out = buf.Bytes()
+	}
+
+	switch last.op {
+	case "&&":
+		if err != nil {
+			return out, err
+		}
+		return last.next.exec(buf != nil)
+	case "||":
+		if err == nil {
+			return out, nil
+		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return out, err
+		}
+		return last.next.exec(buf != nil)
+	default:
+		return out, err
+	}
+}
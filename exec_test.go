@@ -0,0 +1,262 @@
+package commandbuilder
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records the ExecCmd it was asked to run instead of touching the
+// real OS, exercising the injection point Runner/WithRunner document.
+type fakeRunner struct {
+	ran    []*ExecCmd
+	output []byte
+	err    error
+}
+
+func (f *fakeRunner) Run(cmd *ExecCmd) error {
+	f.ran = append(f.ran, cmd)
+	return f.err
+}
+
+func (f *fakeRunner) Output(cmd *ExecCmd) ([]byte, error) {
+	f.ran = append(f.ran, cmd)
+	return f.output, f.err
+}
+
+func (f *fakeRunner) Start(cmd *ExecCmd) error {
+	f.ran = append(f.ran, cmd)
+	return f.err
+}
+
+func TestExecCmdUsesInjectedRunner(t *testing.T) {
+	e, err := New("echo").WithArgument("hi").ToExecCmd()
+	if err != nil {
+		t.Fatalf("ToExecCmd: %v", err)
+	}
+
+	fake := &fakeRunner{output: []byte("hi\n")}
+	e.WithRunner(fake)
+
+	if err := e.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	out, err := e.Output()
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if string(out) != "hi\n" {
+		t.Fatalf("Output = %q, want %q", out, "hi\n")
+	}
+	if len(fake.ran) != 2 {
+		t.Fatalf("runner invoked %d times, want 2", len(fake.ran))
+	}
+
+	fake.err = errors.New("boom")
+	if err := e.Run(); !errors.Is(err, fake.err) {
+		t.Fatalf("Run err = %v, want %v", err, fake.err)
+	}
+}
+
+// TestThreeStagePipeOutput drives a real, 3-stage pipe through the
+// shell-free exec path. It guards against chain (PipeTo/And/Or) returning
+// the wrong builder and silently dropping a middle stage: with that bug,
+// this runs as "printf ... | wc -l" instead of "printf ... | grep b | wc -l"
+// and the middle grep is never invoked.
+func TestThreeStagePipeOutput(t *testing.T) {
+	for _, bin := range []string{"printf", "grep", "wc"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not available", bin)
+		}
+	}
+
+	head := New("printf").WithArgument("%s\\n").WithArgument("a").WithArgument("b").WithArgument("c")
+	middle := New("grep").WithArgument("b")
+	tail := New("wc").WithArgument("-l")
+	head.PipeTo(middle).PipeTo(tail)
+
+	e, err := head.ToExecCmd()
+	if err != nil {
+		t.Fatalf("ToExecCmd: %v", err)
+	}
+	out, err := e.Output()
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if got, want := string(out), "1\n"; got != want {
+		t.Fatalf("Output = %q, want %q", got, want)
+	}
+}
+
+// TestGroupedBackgroundSurviveFluentChaining guards against Grouped/
+// Background/Build operating on whichever *Command node a CommandBuilder
+// happens to wrap instead of the whole chain: PipeTo/And/Or return the
+// newly-chained stage's builder (see its doc comment), so the natural
+// one-liner "head.PipeTo(mid).PipeTo(tail).Grouped()" must still group and
+// render every stage - not silently mark only tail, and not drop mid/tail
+// when Build is later called on head.
+func TestGroupedBackgroundSurviveFluentChaining(t *testing.T) {
+	head := New("cmd1")
+	mid := New("cmd2")
+	tail := New("cmd3")
+	result := head.PipeTo(mid).PipeTo(tail)
+	result.Grouped()
+	result.Background()
+
+	if got, want := head.Build(), "(cmd1 | cmd2 | cmd3) &"; got != want {
+		t.Fatalf("head.Build() = %q, want %q", got, want)
+	}
+	if got, want := result.Build(), "(cmd1 | cmd2 | cmd3) &"; got != want {
+		t.Fatalf("result.Build() = %q, want %q", got, want)
+	}
+}
+
+// TestExecCmdWiresFileRedirection guards against the shell-free exec path
+// silently dropping RedirectToFile/RedirectFromFile: without wireIO, the
+// Command carries the redirection but the underlying *exec.Cmd never sees it.
+func TestExecCmdWiresFileRedirection(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("redirected\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	e, err := New("cat").RedirectFromFile(in).RedirectToFile(out).ToExecCmd()
+	if err != nil {
+		t.Fatalf("ToExecCmd: %v", err)
+	}
+	if err := e.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "redirected\n" {
+		t.Fatalf("out.txt = %q, want %q", got, "redirected\n")
+	}
+}
+
+// TestExecCmdWiresHeredocStdin guards against WithHeredoc being ignored by
+// the exec path, including the indented ("<<-TAG") form's leading-tab strip.
+func TestExecCmdWiresHeredocStdin(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	e, err := New("cat").WithHeredocIndented("EOF", "\tline one\n\tline two").ToExecCmd()
+	if err != nil {
+		t.Fatalf("ToExecCmd: %v", err)
+	}
+	out, err := e.Output()
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if got, want := string(out), "line one\nline two\n"; got != want {
+		t.Fatalf("Output = %q, want %q", got, want)
+	}
+}
+
+// TestExecCmdRejectsFDRedirect documents that RedirectFD/RedirectFDToFile
+// can't be expressed on a plain *exec.Cmd, so ToExecCmd fails closed instead
+// of silently running without the descriptor swap a Build()-rendered shell
+// command would have performed.
+func TestExecCmdRejectsFDRedirect(t *testing.T) {
+	_, err := New("echo").RedirectFD(2, 1).ToExecCmd()
+	if err == nil {
+		t.Fatal("ToExecCmd: want error for fd redirection, got nil")
+	}
+	if !strings.Contains(err.Error(), "RedirectFD") {
+		t.Fatalf("ToExecCmd err = %v, want mention of RedirectFD", err)
+	}
+}
+
+// TestExecCmdWiresTempFileArgAndEnv guards against WithTempFile's generated
+// path being unreachable from the command it was created for: WithTempFileArg
+// and WithTempFileEnv must resolve to the same real, materialized path that
+// ToExecCmd wrote to disk, both in argv and in the process environment.
+func TestExecCmdWiresTempFileArgAndEnv(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	e, err := New("cat").
+		WithTempFileArg("from arg\n", 0600).
+		WithTempFileEnv("CB_TEMP_FILE", "from env\n", 0600).
+		ToExecCmd()
+	if err != nil {
+		t.Fatalf("ToExecCmd: %v", err)
+	}
+
+	argPath := e.Args()[1]
+	if _, err := os.Stat(argPath); err != nil {
+		t.Fatalf("temp file arg path %q does not exist: %v", argPath, err)
+	}
+
+	var envPath string
+	for _, kv := range e.GetCmd().Env {
+		if strings.HasPrefix(kv, "CB_TEMP_FILE=") {
+			envPath = strings.TrimPrefix(kv, "CB_TEMP_FILE=")
+		}
+	}
+	if envPath == "" {
+		t.Fatal("CB_TEMP_FILE not set in process environment")
+	}
+	if envPath == argPath {
+		t.Fatalf("CB_TEMP_FILE path %q reused the arg's temp file, want a distinct file", envPath)
+	}
+
+	out, err := e.Output()
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if string(out) != "from arg\n" {
+		t.Fatalf("Output = %q, want %q", out, "from arg\n")
+	}
+}
+
+// TestBuildRendersTempFilePlaceholder guards against WithTempFileArg/
+// WithTempFileEnv silently rendering as an empty string or a missing env
+// assignment in Build()'s shell string: Build() never materializes a temp
+// file, so it can't emit the real path, but it must still say so with a
+// "<tempfile>" placeholder rather than a blank argument or a dropped
+// variable - and ToExecCmd (which also calls through this rendering path
+// for its log line) must not panic when one is present.
+func TestBuildRendersTempFilePlaceholder(t *testing.T) {
+	if got, want := New("cat").WithTempFileArg("hi\n", 0600).Build(), "cat <tempfile>"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+
+	if got, want := New("cat").WithTempFileEnv("CB_TEMP_FILE", "hi\n", 0600).Build(), "CB_TEMP_FILE=<tempfile> cat"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+
+	if _, err := New("cat").WithTempFileArg("hi\n", 0600).ToExecCmd(); err != nil {
+		t.Fatalf("ToExecCmd: %v", err)
+	}
+}
+
+// TestExecCmdRejectsProcessSubstitution guards against a process-
+// substitution argument silently rendering as an empty argv entry (so
+// "diff <(sort a.txt) b.txt" would run as "diff ” b.txt" instead of
+// failing): os/exec has no equivalent of a shell's "<(cmd)", so ToExecCmd
+// should fail closed the same way it does for RedirectFD.
+func TestExecCmdRejectsProcessSubstitution(t *testing.T) {
+	inner := New("sort").WithArgument("a.txt")
+	_, err := New("diff").WithProcessSubstitution(inner, ProcSubRead).WithArgument("b.txt").ToExecCmd()
+	if err == nil {
+		t.Fatal("ToExecCmd: want error for process substitution, got nil")
+	}
+	if !strings.Contains(err.Error(), "process substitution") {
+		t.Fatalf("ToExecCmd err = %v, want mention of process substitution", err)
+	}
+}
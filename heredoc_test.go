@@ -0,0 +1,63 @@
+package commandbuilder
+
+import "testing"
+
+func TestHeredocRendersBetweenTagMarkers(t *testing.T) {
+	cb := New("cat").WithHeredoc("EOF", "line one\nline two")
+	want := "cat <<EOF\nline one\nline two\nEOF"
+	if got := cb.Build(); got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+}
+
+func TestHeredocIndentedUsesDashMarker(t *testing.T) {
+	cb := New("cat").WithHeredocIndented("EOF", "\tindented line")
+	want := "cat <<-EOF\n\tindented line\nEOF"
+	if got := cb.Build(); got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+}
+
+func TestHeredocPanicsWhenTagAppearsInBody(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithHeredoc did not panic with tag present in body")
+		}
+	}()
+	New("cat").WithHeredoc("EOF", "one\nEOF\ntwo")
+}
+
+func TestHeredocIndentedPanicAllowsLeadingTabsBeforeTag(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithHeredocIndented did not panic with tag present (with leading tabs) in body")
+		}
+	}()
+	New("cat").WithHeredocIndented("EOF", "one\n\t\tEOF\ntwo")
+}
+
+// TestHeredocPlainFormAllowsTabIndentedTagLine guards against WithHeredoc
+// (the plain "<<TAG" form, not "<<-TAG") rejecting a tab-indented tag line:
+// a real "<<TAG" heredoc only terminates on an exact, unindented match, so
+// "\tEOF" inside the body is ordinary content, not an early terminator.
+func TestHeredocPlainFormAllowsTabIndentedTagLine(t *testing.T) {
+	cb := New("cat").WithHeredoc("EOF", "one\n\tEOF\ntwo")
+	want := "cat <<EOF\none\n\tEOF\ntwo\nEOF"
+	if got := cb.Build(); got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessSubstitutionRendersDirection(t *testing.T) {
+	read := New("diff").
+		WithProcessSubstitution(New("sort").WithArgument("a.txt"), ProcSubRead).
+		WithProcessSubstitution(New("sort").WithArgument("b.txt"), ProcSubRead)
+	if got, want := read.Build(), "diff <(sort a.txt) <(sort b.txt)"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+
+	write := New("tee").WithProcessSubstitution(New("gzip"), ProcSubWrite)
+	if got, want := write.Build(), "tee >(gzip)"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+}
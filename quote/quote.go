@@ -0,0 +1,181 @@
+// Package quote implements POSIX and Windows argument quoting, with a
+// pluggable Policy so CommandBuilder can target a shell other than the
+// default POSIX one.
+package quote
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy decides how a single argument value is rendered for a target shell.
+// Quote is used for plain values; QuoteExpand is used for values that should
+// still allow "$VAR"/"${VAR}"/backtick expansion (e.g. quoted arguments built
+// from WithVariable).
+type Policy interface {
+	Quote(value string) string
+	QuoteExpand(value string) string
+}
+
+// POSIX is the default Policy, used by sh/bash/zsh.
+var POSIX Policy = posixPolicy{}
+
+// Windows is a Policy for cmd.exe / PowerShell argument quoting.
+var Windows Policy = windowsPolicy{}
+
+type posixPolicy struct{}
+
+// Quote single-quotes value, escaping any embedded single quotes by closing
+// the quote, emitting an escaped quote, and reopening it. Single quotes
+// preserve every other byte - control characters included - literally, so
+// no separate escaping scheme is needed for them; reaching for bash/zsh's
+// non-POSIX $'...' syntax here would silently break on a plain POSIX /bin/sh
+// like dash, which doesn't support it.
+func (posixPolicy) Quote(value string) string {
+	if value == "" {
+		return "''"
+	}
+	if !needsQuoting(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// QuoteExpand double-quotes value, which still lets the shell expand
+// "$VAR", "${VAR}", and backticks, while protecting everything else.
+func (posixPolicy) QuoteExpand(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func needsQuoting(s string) bool {
+	if strings.ContainsAny(s, " \t\n\"'$&;|<>`\\()[]{}*?!~#") {
+		return true
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+type windowsPolicy struct{}
+
+// Quote follows cmd.exe's quoting rules: wrap in double quotes whenever the
+// value contains anything cmd.exe treats specially, doubling embedded quotes.
+func (windowsPolicy) Quote(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if !strings.ContainsAny(value, " \t\"&|<>^%") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// QuoteExpand is identical to Quote: cmd.exe has no equivalent to POSIX's
+// expand-preserving double quotes.
+func (windowsPolicy) QuoteExpand(value string) string {
+	return windowsPolicy{}.Quote(value)
+}
+
+// ShellQuoteArgs joins args into a single POSIX shell command line, quoting
+// each argument as needed.
+func ShellQuoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = POSIX.Quote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// MustParse tokenizes a POSIX shell command line into an argv vector,
+// honoring single quotes, double quotes, and backslash escapes. It panics on
+// malformed input (e.g. an unterminated quote); use it only for trusted,
+// literal command lines, not user input.
+func MustParse(line string) []string {
+	argv, err := parse(line)
+	if err != nil {
+		panic("quote: " + err.Error())
+	}
+	return argv
+}
+
+// isDoubleQuoteEscapable reports whether a backslash inside a double-quoted
+// string is itself special before b, per POSIX: a backslash retains its
+// literal value unless it precedes "$", "`", '"', "\", or a newline, in
+// which case the backslash is dropped and only b survives.
+func isDoubleQuoteEscapable(b byte) bool {
+	switch b {
+	case '$', '`', '"', '\\', '\n':
+		return true
+	}
+	return false
+}
+
+func parse(line string) ([]string, error) {
+	var argv []string
+	var cur strings.Builder
+	inWord := false
+	i := 0
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				argv = append(argv, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+			i++
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated ' quote")
+			}
+			cur.WriteString(line[i+1 : i+1+end])
+			inWord = true
+			i += end + 2
+		case c == '"':
+			j := i + 1
+			for j < len(line) && line[j] != '"' {
+				if line[j] == '\\' && j+1 < len(line) && isDoubleQuoteEscapable(line[j+1]) {
+					cur.WriteByte(line[j+1])
+					j += 2
+					continue
+				}
+				cur.WriteByte(line[j])
+				j++
+			}
+			if j >= len(line) {
+				return nil, fmt.Errorf(`unterminated " quote`)
+			}
+			inWord = true
+			i = j + 1
+		case c == '\\' && i+1 < len(line):
+			cur.WriteByte(line[i+1])
+			inWord = true
+			i += 2
+		default:
+			cur.WriteByte(c)
+			inWord = true
+			i++
+		}
+	}
+	if inWord {
+		argv = append(argv, cur.String())
+	}
+	return argv, nil
+}
@@ -0,0 +1,102 @@
+package quote
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellQuoteArgsJoinsQuotedArgs(t *testing.T) {
+	got := ShellQuoteArgs([]string{"echo", "hello world", "it's"})
+	want := `echo 'hello world' 'it'"'"'s'`
+	if got != want {
+		t.Fatalf("ShellQuoteArgs = %q, want %q", got, want)
+	}
+}
+
+func TestShellQuoteArgsLeavesPlainArgsUnquoted(t *testing.T) {
+	got := ShellQuoteArgs([]string{"ls", "-la", "file.txt"})
+	want := "ls -la file.txt"
+	if got != want {
+		t.Fatalf("ShellQuoteArgs = %q, want %q", got, want)
+	}
+}
+
+func TestMustParseSplitsOnWhitespace(t *testing.T) {
+	got := MustParse("ls -la file.txt")
+	want := []string{"ls", "-la", "file.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MustParse = %v, want %v", got, want)
+	}
+}
+
+func TestMustParseHonorsSingleQuotes(t *testing.T) {
+	got := MustParse(`echo 'hello world'`)
+	want := []string{"echo", "hello world"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MustParse = %v, want %v", got, want)
+	}
+}
+
+// TestMustParseDoubleQuoteEscapesOnlyDropBackslashBeforeSpecials guards
+// against a regression where every backslash inside a double-quoted string
+// kept its backslash attached except before '"' and '\': per POSIX, a
+// backslash also loses itself before '$' and '`', and a plain backslash
+// before an ordinary letter is not an escape at all and must survive as a
+// literal backslash in the parsed token.
+func TestMustParseDoubleQuoteEscapesOnlyDropBackslashBeforeSpecials(t *testing.T) {
+	got := MustParse(`"\$foo"`)
+	want := []string{"$foo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MustParse = %v, want %v", got, want)
+	}
+
+	got = MustParse("\"\\`foo\\`\"")
+	want = []string{"`foo`"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MustParse = %v, want %v", got, want)
+	}
+
+	got = MustParse(`"\foo"`)
+	want = []string{`\foo`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MustParse = %v, want %v", got, want)
+	}
+
+	got = MustParse(`"say \"hi\""`)
+	want = []string{`say "hi"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MustParse = %v, want %v", got, want)
+	}
+}
+
+func TestMustParsePanicsOnUnterminatedQuote(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustParse did not panic on unterminated quote")
+		}
+	}()
+	MustParse(`echo "unterminated`)
+}
+
+func TestWindowsQuoteWrapsValuesWithSpecialChars(t *testing.T) {
+	got := Windows.Quote(`say "hi"`)
+	want := `"say ""hi"""`
+	if got != want {
+		t.Fatalf("Windows.Quote = %q, want %q", got, want)
+	}
+
+	if got, want := Windows.Quote("plain"), "plain"; got != want {
+		t.Fatalf("Windows.Quote = %q, want %q", got, want)
+	}
+
+	if got, want := Windows.Quote(""), `""`; got != want {
+		t.Fatalf("Windows.Quote(\"\") = %q, want %q", got, want)
+	}
+}
+
+func TestWindowsQuoteExpandMatchesQuote(t *testing.T) {
+	value := `needs "quoting"`
+	if got, want := Windows.QuoteExpand(value), Windows.Quote(value); got != want {
+		t.Fatalf("Windows.QuoteExpand = %q, want %q", got, want)
+	}
+}
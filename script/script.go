@@ -0,0 +1,320 @@
+// Package script parses a small shell-like scripting language into
+// commandbuilder chains, modeled on the command registry and line-based
+// condition guards used by cmd/go/internal/script.
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/vts0/commandbuilder"
+)
+
+// Engine holds the set of command names the parser knows about. An Engine
+// with no registered commands still parses any command name; Register only
+// adds usage validation for known ones.
+type Engine struct {
+	commands map[string]Usage
+}
+
+// Usage declares a command's expected argument count, so Parse can report a
+// useful error instead of silently misparsing.
+type Usage struct {
+	MinArgs int
+	MaxArgs int // -1 means unbounded
+}
+
+// NewEngine returns an Engine with no registered commands.
+func NewEngine() *Engine {
+	return &Engine{commands: make(map[string]Usage)}
+}
+
+// Register declares a known command name's argument-count contract.
+func (e *Engine) Register(name string, usage Usage) {
+	e.commands[name] = usage
+}
+
+// Parse reads a multiline script (one pipeline per line; "|", "&&", "||",
+// "&", "( ... )" grouping, and "KEY=value" env prefixes; "[os] cmd"
+// condition guards) and returns one commandbuilder chain per line, in
+// script order. Each line is independent; Parse does not chain one line's
+// command into the next.
+func (e *Engine) Parse(script string) ([]*commandbuilder.CommandBuilder, error) {
+	var builders []*commandbuilder.CommandBuilder
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line, ok := stripCondition(line)
+		if !ok {
+			continue // condition didn't match this platform; skip the line
+		}
+
+		cb, err := e.parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("script: line %d: %w", lineNo, err)
+		}
+		builders = append(builders, cb)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(builders) == 0 {
+		return nil, fmt.Errorf("script: no commands found")
+	}
+	return builders, nil
+}
+
+// stripCondition removes a leading "[os]" guard and reports whether the line
+// should be kept for the current GOOS.
+func stripCondition(line string) (string, bool) {
+	if !strings.HasPrefix(line, "[") {
+		return line, true
+	}
+	end := strings.IndexByte(line, ']')
+	if end < 0 {
+		return line, true
+	}
+	cond := strings.TrimSpace(line[1:end])
+	rest := strings.TrimSpace(line[end+1:])
+	negate := strings.HasPrefix(cond, "!")
+	cond = strings.TrimPrefix(cond, "!")
+	matches := cond == runtime.GOOS
+	if negate {
+		matches = !matches
+	}
+	return rest, matches
+}
+
+// parseLine parses a single pipeline: one or more commands joined by "|",
+// "&&", or "||", optionally wrapped in "( ... )" subshell grouping and
+// backgrounded with a trailing "&".
+func (e *Engine) parseLine(line string) (*commandbuilder.CommandBuilder, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	background := false
+	if tokens[len(tokens)-1] == "&" {
+		background = true
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	grouped := false
+	if len(tokens) >= 2 && tokens[0] == "(" && tokens[len(tokens)-1] == ")" {
+		grouped = true
+		tokens = tokens[1 : len(tokens)-1]
+	}
+
+	segments, ops := splitOnOperators(tokens)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	builders := make([]*commandbuilder.CommandBuilder, len(segments))
+	for i, seg := range segments {
+		cb, err := e.parseSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+		builders[i] = cb
+	}
+
+	head := builders[0]
+	cur := head
+	for i, op := range ops {
+		next := builders[i+1]
+		switch op {
+		case "|":
+			cur.PipeTo(next)
+		case "&&":
+			cur.And(next)
+		case "||":
+			cur.Or(next)
+		}
+		cur = next
+	}
+
+	if grouped {
+		head.Grouped()
+	}
+	if background {
+		head.Background()
+	}
+	return head, nil
+}
+
+// splitOnOperators splits tokens into command segments on "|", "&&", "||",
+// returning the segments and the operators between them in order.
+func splitOnOperators(tokens []string) ([][]string, []string) {
+	var segments [][]string
+	var ops []string
+	var cur []string
+
+	for _, tok := range tokens {
+		switch tok {
+		case "|", "&&", "||":
+			segments = append(segments, cur)
+			ops = append(ops, tok)
+			cur = nil
+		default:
+			cur = append(cur, tok)
+		}
+	}
+	segments = append(segments, cur)
+	return segments, ops
+}
+
+// parseSegment parses one command (env prefixes, name, args, and basic
+// redirections) into a CommandBuilder.
+func (e *Engine) parseSegment(tokens []string) (*commandbuilder.CommandBuilder, error) {
+	var envPairs [][2]string
+	i := 0
+	for ; i < len(tokens); i++ {
+		k, v, ok := splitEnvPrefix(tokens[i])
+		if !ok {
+			break
+		}
+		envPairs = append(envPairs, [2]string{k, v})
+	}
+	if i >= len(tokens) {
+		return nil, fmt.Errorf("missing command name")
+	}
+
+	name := tokens[i]
+	if usage, known := e.commands[name]; known {
+		argCount := len(tokens) - i - 1
+		if argCount < usage.MinArgs || (usage.MaxArgs >= 0 && argCount > usage.MaxArgs) {
+			return nil, fmt.Errorf("%s: wrong number of arguments", name)
+		}
+	}
+
+	cb := commandbuilder.New(name)
+	for _, pair := range envPairs {
+		cb.WithEnv(pair[0], pair[1])
+	}
+
+	for i++; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok {
+		case ">":
+			i++
+			cb.RedirectToFile(tokens[i])
+		case ">>":
+			i++
+			cb.AppendToFile(tokens[i])
+		case "<":
+			i++
+			cb.RedirectFromFile(tokens[i])
+		case "2>":
+			i++
+			cb.RedirectStderrToFile(tokens[i])
+		case "2>&1":
+			cb.MergeStdoutAndStderr()
+		default:
+			cb.WithArgument(tok)
+		}
+	}
+	return cb, nil
+}
+
+// splitEnvPrefix reports whether tok looks like "KEY=value".
+func splitEnvPrefix(tok string) (key, value string, ok bool) {
+	eq := strings.IndexByte(tok, '=')
+	if eq <= 0 {
+		return "", "", false
+	}
+	key = tok[:eq]
+	for _, r := range key {
+		if !(r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) {
+			return "", "", false
+		}
+	}
+	return key, tok[eq+1:], true
+}
+
+// tokenize splits a line into words, honoring single and double quotes.
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inWord := false
+	i := 0
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == '\'' || c == '"':
+			quote := c
+			i++
+			start := i
+			for i < len(line) && line[i] != quote {
+				i++
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("unterminated %c quote", quote)
+			}
+			cur.WriteString(line[start:i])
+			inWord = true
+			i++
+		case c == ' ' || c == '\t':
+			if inWord {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+			i++
+		case c == '|' && i+1 < len(line) && line[i+1] == '|':
+			flushWord(&tokens, &cur, &inWord)
+			tokens = append(tokens, "||")
+			i += 2
+		case c == '&' && i+1 < len(line) && line[i+1] == '&':
+			flushWord(&tokens, &cur, &inWord)
+			tokens = append(tokens, "&&")
+			i += 2
+		case c == '>' && i+1 < len(line) && line[i+1] == '>':
+			flushWord(&tokens, &cur, &inWord)
+			tokens = append(tokens, ">>")
+			i += 2
+		case c == '2' && !inWord && strings.HasPrefix(line[i:], "2>&1"):
+			tokens = append(tokens, "2>&1")
+			i += 4
+		case c == '2' && !inWord && strings.HasPrefix(line[i:], "2>"):
+			tokens = append(tokens, "2>")
+			i += 2
+		case c == '|' || c == '&' || c == '>' || c == '<' || c == '(' || c == ')':
+			flushWord(&tokens, &cur, &inWord)
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			cur.WriteByte(c)
+			inWord = true
+			i++
+		}
+	}
+	flushWord(&tokens, &cur, &inWord)
+	return tokens, nil
+}
+
+func flushWord(tokens *[]string, cur *strings.Builder, inWord *bool) {
+	if *inWord {
+		*tokens = append(*tokens, cur.String())
+		cur.Reset()
+		*inWord = false
+	}
+}
+
+// Render renders a CommandBuilder chain back to script form. It is the
+// inverse of Parse for the subset of syntax Parse accepts: Parse(Render(cb))
+// produces an equivalent chain.
+func Render(cb *commandbuilder.CommandBuilder) string {
+	return cb.Build()
+}
@@ -0,0 +1,106 @@
+package script
+
+import "testing"
+
+func TestParseReturnsEveryLine(t *testing.T) {
+	builders, err := NewEngine().Parse("echo one\necho two\necho three")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(builders) != 3 {
+		t.Fatalf("Parse returned %d builders, want 3", len(builders))
+	}
+
+	want := []string{"echo one", "echo two", "echo three"}
+	for i, cb := range builders {
+		if got := cb.Build(); got != want[i] {
+			t.Fatalf("builders[%d].Build() = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestParseRenderRoundTrip(t *testing.T) {
+	builders, err := NewEngine().Parse("FOO=bar ls -la | grep foo && echo ok")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(builders) != 1 {
+		t.Fatalf("got %d builders, want 1", len(builders))
+	}
+
+	rendered := Render(builders[0])
+	reparsed, err := NewEngine().Parse(rendered)
+	if err != nil {
+		t.Fatalf("Parse(Render(cb)): %v", err)
+	}
+	if len(reparsed) != 1 {
+		t.Fatalf("reparsed got %d builders, want 1", len(reparsed))
+	}
+	if got, want := Render(reparsed[0]), rendered; got != want {
+		t.Fatalf("Parse(Render(cb)) round-trip = %q, want %q", got, want)
+	}
+}
+
+func TestParseGrouping(t *testing.T) {
+	builders, err := NewEngine().Parse("(ls -la)")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := Render(builders[0]), "(ls -la)"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestParseGroupingWithBackground(t *testing.T) {
+	builders, err := NewEngine().Parse("(ls -la) &")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := Render(builders[0]), "(ls -la) &"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+}
+
+// TestParseGroupingAndBackgroundSpanWholePipe guards against Grouped/
+// Background being applied only to the pipe's first stage: "(" must wrap
+// every stage and "&" must trail the whole chain, not land between stages.
+func TestParseGroupingAndBackgroundSpanWholePipe(t *testing.T) {
+	builders, err := NewEngine().Parse("(cmd1 | cmd2)")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := Render(builders[0]), "(cmd1 | cmd2)"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+
+	builders, err = NewEngine().Parse("cmd1 | cmd2 &")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := Render(builders[0]), "cmd1 | cmd2 &"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+}
+
+// TestTokenizeOnlyTreatsLeadingTwoAsFDRedirect guards against a word ending
+// in "2" immediately followed by ">" being mis-split into that word plus a
+// synthetic "2>" token: real shells only recognize the fd-redirect prefix
+// when the digit itself starts a fresh token, so "foo2>bar" is the argument
+// "foo2" with stdout redirected to "bar", not "foo" with stderr redirected.
+func TestTokenizeOnlyTreatsLeadingTwoAsFDRedirect(t *testing.T) {
+	builders, err := NewEngine().Parse("echo foo2>bar")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := Render(builders[0]), "echo foo2 > bar"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+
+	builders, err = NewEngine().Parse("echo foo 2> bar")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := Render(builders[0]), "echo foo 2> bar"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+}
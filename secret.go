@@ -0,0 +1,111 @@
+package commandbuilder
+
+import "context"
+
+// redacted is substituted for any secret value rendered by BuildRedacted,
+// String, or a Logger event.
+const redacted = "***"
+
+// SecretProvider resolves a secret value lazily, e.g. from a keyring or
+// vault, instead of requiring the caller to hold the plaintext up front.
+type SecretProvider interface {
+	Fetch(ctx context.Context) (string, error)
+}
+
+// WithSecretArgument adds a plain argument whose value is treated as
+// sensitive: Build still emits it, but BuildRedacted and String replace it
+// with "***".
+func (b *CommandBuilder) WithSecretArgument(value string) *CommandBuilder {
+	b.command.args = append(b.command.args, argument{value: value, secret: true})
+	return b
+}
+
+// WithSecretEnv sets an environment variable whose value is treated as
+// sensitive.
+func (b *CommandBuilder) WithSecretEnv(key, value string) *CommandBuilder {
+	b.command.env[key] = value
+	if b.command.secretEnvKeys == nil {
+		b.command.secretEnvKeys = make(map[string]bool)
+	}
+	b.command.secretEnvKeys[key] = true
+	return b
+}
+
+// WithSecretKeyValue adds a "key=value" argument whose value is treated as
+// sensitive.
+func (b *CommandBuilder) WithSecretKeyValue(key, value string) *CommandBuilder {
+	b.command.args = append(b.command.args, argument{keyValue: true, key: key, value: value, secret: true})
+	return b
+}
+
+// WithSecretArgumentFrom adds an argument whose value is resolved lazily from
+// provider each time the command is built or executed, rather than being
+// held in memory up front.
+func (b *CommandBuilder) WithSecretArgumentFrom(provider SecretProvider) *CommandBuilder {
+	b.command.args = append(b.command.args, argument{secret: true, secretProvider: provider})
+	return b
+}
+
+// WithLogger registers a hook invoked before the command is run via the exec
+// subsystem. It receives the redacted command line, so secret values never
+// reach logs.
+func (b *CommandBuilder) WithLogger(fn func(event string)) *CommandBuilder {
+	b.logger = fn
+	return b
+}
+
+// resolveSecretProviders fetches any lazily-resolved secret argument values
+// and fills them in, returning the first error encountered, if any. It
+// recurses into process-substitution arguments' inner builders, since those
+// carry their own independent Command chain that Build/ToExecCmd also render.
+func (b *CommandBuilder) resolveSecretProviders(ctx context.Context) error {
+	for cmd := b.chainHead(); cmd != nil; cmd = cmd.next {
+		for i := range cmd.args {
+			arg := &cmd.args[i]
+			if arg.procSub != nil {
+				if err := arg.procSub.resolveSecretProviders(ctx); err != nil {
+					return err
+				}
+			}
+			if arg.secretProvider == nil {
+				continue
+			}
+			value, err := arg.secretProvider.Fetch(ctx)
+			if err != nil {
+				return err
+			}
+			arg.value = value
+		}
+	}
+	return nil
+}
+
+// BuildRedacted renders the command like Build, but with every secret
+// argument or environment value replaced with "***".
+func (b *CommandBuilder) BuildRedacted() string {
+	return b.buildWithRedaction(true)
+}
+
+// String implements fmt.Stringer by rendering the command with secrets
+// redacted, so it's always safe to log a CommandBuilder directly.
+func (b *CommandBuilder) String() string {
+	return b.BuildRedacted()
+}
+
+// GoString implements fmt.GoStringer the same way String does, so %#v on a
+// CommandBuilder doesn't leak secrets either.
+func (b *CommandBuilder) GoString() string {
+	return b.BuildRedacted()
+}
+
+// String implements fmt.Stringer for a single Command node, rendering it
+// (and anything it's chained to) with secrets redacted.
+func (c *Command) String() string {
+	return (&CommandBuilder{command: c}).BuildRedacted()
+}
+
+// GoString implements fmt.GoStringer for a single Command node the same way
+// String does.
+func (c *Command) GoString() string {
+	return c.String()
+}
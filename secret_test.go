@@ -0,0 +1,70 @@
+package commandbuilder
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeProvider struct {
+	value string
+	err   error
+}
+
+func (p fakeProvider) Fetch(ctx context.Context) (string, error) {
+	return p.value, p.err
+}
+
+func TestBuildResolvesSecretProvider(t *testing.T) {
+	cb := New("curl").WithSecretArgumentFrom(fakeProvider{value: "s3cr3t"})
+
+	if got, want := cb.Build(), "curl s3cr3t"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+	if got, want := cb.String(), "curl '***'"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildDoesNotFailOnProviderError(t *testing.T) {
+	cb := New("curl").WithSecretArgumentFrom(fakeProvider{err: errors.New("unreachable")})
+
+	if got, want := cb.Build(), "curl ''"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+}
+
+func TestToExecCmdPropagatesSecretProviderError(t *testing.T) {
+	boom := errors.New("vault unreachable")
+	cb := New("curl").WithSecretArgumentFrom(fakeProvider{err: boom})
+
+	if _, err := cb.ToExecCmd(); !errors.Is(err, boom) {
+		t.Fatalf("ToExecCmd err = %v, want %v", err, boom)
+	}
+}
+
+// TestBuildResolvesSecretProviderInsideProcessSubstitution guards against
+// resolveSecretProviders only walking cmd.next and skipping a procSub
+// argument's own inner builder, which would render the secret as an empty
+// string instead of either its value (Build) or "***" (String).
+func TestBuildResolvesSecretProviderInsideProcessSubstitution(t *testing.T) {
+	inner := New("echo").WithSecretArgumentFrom(fakeProvider{value: "s3cr3t"})
+	cb := New("diff").WithProcessSubstitution(inner, ProcSubRead)
+
+	if got, want := cb.Build(), "diff <(echo s3cr3t)"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+	if got, want := cb.String(), "diff <(echo '***')"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestToExecCmdPropagatesSecretProviderErrorInsideProcessSubstitution(t *testing.T) {
+	boom := errors.New("vault unreachable")
+	inner := New("echo").WithSecretArgumentFrom(fakeProvider{err: boom})
+	cb := New("diff").WithProcessSubstitution(inner, ProcSubRead)
+
+	if _, err := cb.ToExecCmd(); !errors.Is(err, boom) {
+		t.Fatalf("ToExecCmd err = %v, want %v", err, boom)
+	}
+}
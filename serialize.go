@@ -0,0 +1,218 @@
+package commandbuilder
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// CurrentSchemaVersion is written by MarshalJSON and checked by UnmarshalJSON
+// so future format changes can migrate older payloads.
+const CurrentSchemaVersion = 1
+
+// CommandData is the stable, serializable shape of a Command, used by
+// MarshalJSON/UnmarshalJSON and the commandbuilder/yaml helper. It exists
+// apart from Command so the wire format doesn't need to track every
+// unexported field verbatim (e.g. stdin, which isn't serializable).
+type CommandData struct {
+	SchemaVersion  int               `json:"schema_version" yaml:"schema_version"`
+	Path           string            `json:"path" yaml:"path"`
+	Subcommands    []string          `json:"subcommands,omitempty" yaml:"subcommands,omitempty"`
+	Args           []ArgumentData    `json:"args,omitempty" yaml:"args,omitempty"`
+	EnvUpdates     map[string]string `json:"env_updates,omitempty" yaml:"env_updates,omitempty"`
+	SecretEnvKeys  []string          `json:"secret_env_keys,omitempty" yaml:"secret_env_keys,omitempty"`
+	Sudo           bool              `json:"sudo,omitempty" yaml:"sudo,omitempty"`
+	Redirects      []string          `json:"redirects,omitempty" yaml:"redirects,omitempty"`
+	StderrRedirect string            `json:"stderr_redirect,omitempty" yaml:"stderr_redirect,omitempty"`
+	MergeStdErr    bool              `json:"merge_stderr,omitempty" yaml:"merge_stderr,omitempty"`
+	Background     bool              `json:"background,omitempty" yaml:"background,omitempty"`
+	Group          bool              `json:"group,omitempty" yaml:"group,omitempty"`
+	TempFiles      []TempFileData    `json:"temp_files,omitempty" yaml:"temp_files,omitempty"`
+	HeredocTag     string            `json:"heredoc_tag,omitempty" yaml:"heredoc_tag,omitempty"`
+	HeredocBody    string            `json:"heredoc_body,omitempty" yaml:"heredoc_body,omitempty"`
+	HeredocIndent  bool              `json:"heredoc_indented,omitempty" yaml:"heredoc_indented,omitempty"`
+	Herestring     string            `json:"herestring,omitempty" yaml:"herestring,omitempty"`
+	FDRedirects    []string          `json:"fd_redirects,omitempty" yaml:"fd_redirects,omitempty"`
+	Chain          *ChainData        `json:"chain,omitempty" yaml:"chain,omitempty"`
+}
+
+// ChainData describes how a Command continues into the next one.
+type ChainData struct {
+	Op   string       `json:"op" yaml:"op"`
+	Next *CommandData `json:"next" yaml:"next"`
+}
+
+// ArgumentData is the serializable shape of a single argument. A
+// WithSecretArgumentFrom argument round-trips as a plain Secret value: the
+// SecretProvider itself isn't serializable, so only whatever value it last
+// resolved to (often none) survives, and the reconstructed argument is no
+// longer lazy.
+type ArgumentData struct {
+	Value     string       `json:"value" yaml:"value"`
+	Quoted    bool         `json:"quoted,omitempty" yaml:"quoted,omitempty"`
+	Expand    bool         `json:"expand,omitempty" yaml:"expand,omitempty"`
+	IsPath    bool         `json:"is_path,omitempty" yaml:"is_path,omitempty"`
+	IsGlob    bool         `json:"is_glob,omitempty" yaml:"is_glob,omitempty"`
+	KeyValue  bool         `json:"key_value,omitempty" yaml:"key_value,omitempty"`
+	Key       string       `json:"key,omitempty" yaml:"key,omitempty"`
+	Secret    bool         `json:"secret,omitempty" yaml:"secret,omitempty"`
+	ProcSub   *CommandData `json:"proc_sub,omitempty" yaml:"proc_sub,omitempty"`
+	ProcSubIn bool         `json:"proc_sub_in,omitempty" yaml:"proc_sub_in,omitempty"`
+}
+
+// TempFileData is the serializable shape of a tempFile.
+type TempFileData struct {
+	Content string      `json:"content" yaml:"content"`
+	Mode    os.FileMode `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+// ToData converts c (and anything it's chained to) into its serializable
+// form. Unrepresentable state, like a live stdin io.Reader or a
+// WithSecretArgumentFrom provider (see ArgumentData), is dropped.
+func (c *Command) ToData() *CommandData {
+	if c == nil {
+		return nil
+	}
+
+	data := &CommandData{
+		SchemaVersion:  CurrentSchemaVersion,
+		Path:           c.name,
+		Subcommands:    c.subcommands,
+		Sudo:           c.useSudo,
+		Redirects:      c.redirections,
+		StderrRedirect: c.stderrRedir,
+		MergeStdErr:    c.mergeStdErr,
+		Background:     c.background,
+		Group:          c.group,
+		FDRedirects:    c.fdRedirs,
+		Herestring:     c.herestring,
+	}
+	if len(c.env) > 0 {
+		data.EnvUpdates = c.env
+	}
+	for k := range c.secretEnvKeys {
+		if c.secretEnvKeys[k] {
+			data.SecretEnvKeys = append(data.SecretEnvKeys, k)
+		}
+	}
+	sort.Strings(data.SecretEnvKeys)
+	if c.heredoc != nil {
+		data.HeredocTag = c.heredoc.tag
+		data.HeredocBody = c.heredoc.body
+		data.HeredocIndent = c.heredoc.indented
+	}
+	for _, a := range c.args {
+		ad := ArgumentData{
+			Value: a.value, Quoted: a.quoted, Expand: a.expand,
+			IsPath: a.isPath, IsGlob: a.isGlob, KeyValue: a.keyValue,
+			Key: a.key, Secret: a.secret,
+		}
+		if a.procSub != nil {
+			ad.ProcSub = a.procSub.command.ToData()
+			ad.ProcSubIn = a.procSubIn
+		}
+		data.Args = append(data.Args, ad)
+	}
+	for _, tf := range c.tempFiles {
+		data.TempFiles = append(data.TempFiles, TempFileData{Content: tf.content, Mode: tf.mode})
+	}
+	if c.next != nil {
+		data.Chain = &ChainData{Op: c.operator, Next: c.next.ToData()}
+	}
+	return data
+}
+
+// CommandFromData reconstructs a Command (and its chain) from serialized
+// data, migrating older schema versions first.
+func CommandFromData(data *CommandData) *Command {
+	if data == nil {
+		return nil
+	}
+	migrate(data)
+
+	c := &Command{
+		name:         data.Path,
+		subcommands:  data.Subcommands,
+		useSudo:      data.Sudo,
+		redirections: data.Redirects,
+		stderrRedir:  data.StderrRedirect,
+		mergeStdErr:  data.MergeStdErr,
+		background:   data.Background,
+		group:        data.Group,
+		fdRedirs:     data.FDRedirects,
+		herestring:   data.Herestring,
+		env:          data.EnvUpdates,
+	}
+	if c.env == nil {
+		c.env = make(map[string]string)
+	}
+	for _, k := range data.SecretEnvKeys {
+		if c.secretEnvKeys == nil {
+			c.secretEnvKeys = make(map[string]bool)
+		}
+		c.secretEnvKeys[k] = true
+	}
+	if data.HeredocTag != "" {
+		c.heredoc = &heredoc{tag: data.HeredocTag, body: data.HeredocBody, indented: data.HeredocIndent}
+	}
+	for _, a := range data.Args {
+		arg := argument{
+			value: a.Value, quoted: a.Quoted, expand: a.Expand,
+			isPath: a.IsPath, isGlob: a.IsGlob, keyValue: a.KeyValue,
+			key: a.Key, secret: a.Secret,
+		}
+		if a.ProcSub != nil {
+			arg.procSub = &CommandBuilder{command: CommandFromData(a.ProcSub)}
+			arg.procSubIn = a.ProcSubIn
+		}
+		c.args = append(c.args, arg)
+	}
+	for _, tf := range data.TempFiles {
+		c.tempFiles = append(c.tempFiles, tempFile{content: tf.Content, mode: tf.Mode})
+	}
+	if data.Chain != nil {
+		c.operator = data.Chain.Op
+		c.next = CommandFromData(data.Chain.Next)
+	}
+	return c
+}
+
+// migrate upgrades older schema versions in place. There's only one version
+// today; this is the hook future format changes plug into.
+func migrate(data *CommandData) {
+	if data.SchemaVersion == 0 {
+		data.SchemaVersion = CurrentSchemaVersion
+	}
+}
+
+// MarshalJSON implements json.Marshaler, serializing the full chain
+// (including subcommands, redirections, tempfiles, and per-argument flags)
+// so it can be persisted or sent across processes.
+func (c *Command) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.ToData())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the inverse of MarshalJSON.
+func (c *Command) UnmarshalJSON(raw []byte) error {
+	var data CommandData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+	*c = *CommandFromData(&data)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler by delegating to the underlying
+// Command.
+func (b *CommandBuilder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.command)
+}
+
+// UnmarshalJSON implements json.Unmarshaler by delegating to the underlying
+// Command.
+func (b *CommandBuilder) UnmarshalJSON(raw []byte) error {
+	if b.command == nil {
+		b.command = &Command{}
+	}
+	return b.command.UnmarshalJSON(raw)
+}
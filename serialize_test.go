@@ -0,0 +1,90 @@
+package commandbuilder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTripPreservesPipelineAndEnv(t *testing.T) {
+	cb := New("grep").WithEnv("LANG", "C").WithArgument("foo")
+	next := New("wc").WithArgument("-l")
+	cb.PipeTo(next)
+
+	raw, err := cb.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+
+	var got CommandBuilder
+	if err := got.UnmarshalJSON(raw); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+
+	if want, have := cb.Build(), got.Build(); want != have {
+		t.Fatalf("round-trip Build() = %q, want %q", have, want)
+	}
+}
+
+func TestJSONRoundTripPreservesProcessSubstitution(t *testing.T) {
+	cb := New("diff").WithProcessSubstitution(New("sort").WithArgument("a.txt"), ProcSubRead)
+
+	raw, err := cb.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+
+	var got CommandBuilder
+	if err := got.UnmarshalJSON(raw); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+
+	if want, have := cb.Build(), got.Build(); want != have {
+		t.Fatalf("round-trip Build() = %q, want %q", have, want)
+	}
+}
+
+func TestJSONRoundTripDropsSecretProvider(t *testing.T) {
+	cb := New("curl").WithSecretArgumentFrom(fakeProvider{value: "tok"})
+
+	raw, err := cb.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+
+	var got CommandBuilder
+	if err := got.UnmarshalJSON(raw); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+
+	// The provider itself isn't serializable (see ArgumentData), so the
+	// round-tripped argument is a plain (no longer lazy) secret with
+	// whatever value was last resolved into it - none here.
+	if got, want := got.Build(), "curl ''"; got != want {
+		t.Fatalf("round-trip Build() = %q, want %q", got, want)
+	}
+}
+
+// TestJSONRoundTripPreservesSecretEnvRedaction guards against
+// WithSecretEnv's marking being dropped by the wire format: without it,
+// the reconstructed command's String()/BuildRedacted() would print the raw
+// secret value instead of "***".
+func TestJSONRoundTripPreservesSecretEnvRedaction(t *testing.T) {
+	cb := New("curl").WithSecretEnv("TOKEN", "s3cr3t")
+
+	raw, err := cb.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+
+	var got CommandBuilder
+	if err := got.UnmarshalJSON(raw); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+
+	if want, have := cb.String(), got.String(); want != have {
+		t.Fatalf("round-trip String() = %q, want %q", have, want)
+	}
+	if strings.Contains(got.String(), "s3cr3t") {
+		t.Fatalf("round-trip String() = %q, leaked the secret value", got.String())
+	}
+}
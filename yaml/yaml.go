@@ -0,0 +1,24 @@
+// Package yaml adds YAML (de)serialization for commandbuilder.Command,
+// alongside the JSON support on Command itself.
+package yaml
+
+import (
+	"github.com/vts0/commandbuilder"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Marshal renders a Command graph as YAML, using the same schema as
+// Command.MarshalJSON.
+func Marshal(c *commandbuilder.Command) ([]byte, error) {
+	return yamlv3.Marshal(c.ToData())
+}
+
+// Unmarshal parses YAML produced by Marshal back into a Command graph.
+func Unmarshal(raw []byte, c *commandbuilder.Command) error {
+	var data commandbuilder.CommandData
+	if err := yamlv3.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+	*c = *commandbuilder.CommandFromData(&data)
+	return nil
+}
@@ -0,0 +1,33 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/vts0/commandbuilder"
+)
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	cmd := commandbuilder.CommandFromData(&commandbuilder.CommandData{
+		SchemaVersion: commandbuilder.CurrentSchemaVersion,
+		Path:          "echo",
+		Args: []commandbuilder.ArgumentData{
+			{Value: "hello world"},
+			{Value: "quoted", Quoted: true},
+		},
+		EnvUpdates: map[string]string{"LANG": "C"},
+	})
+
+	raw, err := Marshal(cmd)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got commandbuilder.Command
+	if err := Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if want, have := cmd.String(), got.String(); want != have {
+		t.Fatalf("round-trip String() = %q, want %q", have, want)
+	}
+}